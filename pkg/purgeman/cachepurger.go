@@ -0,0 +1,259 @@
+package purgeman
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// CacheBackendTypeVarnish purges a Varnish-fronted cache with an HTTP
+	// PURGE request, mirroring purgeman's original behavior.
+	CacheBackendTypeVarnish = "varnish"
+	// CacheBackendTypeNginx purges an nginx proxy_cache_purge-style cache.
+	CacheBackendTypeNginx = "nginx"
+	// CacheBackendTypeWebhook posts a JSON purge event to an arbitrary URL,
+	// letting operators fan events out to caches purgeman doesn't know
+	// about natively.
+	CacheBackendTypeWebhook = "webhook"
+
+	defaultPurgeTimeout = 10 * time.Second
+)
+
+// CachePurger is implemented by anything capable of invalidating a cached
+// iRODS path. Backends are instantiated from Config.CacheBackends at
+// PurgemanService.Connect() time and own their own auth, host override,
+// timeout and TLS settings.
+type CachePurger interface {
+	// Name identifies the backend for logging and metrics.
+	Name() string
+	// Purge invalidates irodsPath on the backend. eventType and uuid carry
+	// the fs event that triggered the purge (e.g. for the webhook backend,
+	// which forwards them); backends that only need the path may ignore
+	// them. A non-nil error means the path may still be cached.
+	Purge(eventType string, irodsPath string, uuid string) error
+}
+
+// newCachePurger builds the CachePurger named by cfg.Type.
+func newCachePurger(cfg CacheBackendConfig) (CachePurger, error) {
+	name := cfg.Name
+	if len(name) == 0 {
+		name = cfg.Type
+	}
+
+	timeout := defaultPurgeTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	maxIdleConnsPerHost := cfg.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = 16
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        maxIdleConnsPerHost * 4,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if cfg.TLSInsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+
+	switch cfg.Type {
+	case CacheBackendTypeVarnish:
+		return &varnishPurger{name: name, config: cfg, httpClient: httpClient}, nil
+	case CacheBackendTypeNginx:
+		return &nginxPurger{name: name, config: cfg, httpClient: httpClient}, nil
+	case CacheBackendTypeWebhook:
+		return &webhookPurger{name: name, config: cfg, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend type '%s'", cfg.Type)
+	}
+}
+
+// purgeURLPrefixes sends method to every urlPrefix+path combination in
+// cfg.URLPrefixes, applying the matching HostsOverride entry and auth
+// settings. It is shared by the varnish and nginx backends, which only
+// differ in HTTP method and default auth behavior. A failure on any prefix
+// is reported (not just a total failure across all of them), so the caller's
+// retry/circuit-breaker layer sees and can act on a single node going bad.
+func purgeURLPrefixes(httpClient *http.Client, cfg CacheBackendConfig, method string, irodsPath string) error {
+	var lastErr error
+	failed := 0
+
+	for idx, urlPrefix := range cfg.URLPrefixes {
+		urlPrefix = strings.TrimRight(urlPrefix, "/")
+		requestURL := urlPrefix + irodsPath
+
+		hostOverride := ""
+		if idx < len(cfg.HostsOverride) {
+			hostOverride = cfg.HostsOverride[idx]
+		}
+
+		host := hostOverride
+		if len(host) == 0 {
+			u, err := url.Parse(requestURL)
+			if err != nil {
+				lastErr = fmt.Errorf("failed to parse request url '%s': %w", requestURL, err)
+				failed++
+				continue
+			}
+			host = u.Host
+		}
+
+		req, err := http.NewRequest(method, requestURL, nil)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to create a %s request to url '%s' for host '%s': %w", method, requestURL, host, err)
+			failed++
+			continue
+		}
+
+		if len(hostOverride) > 0 {
+			req.Host = hostOverride
+		}
+
+		applyAuth(req, cfg)
+
+		response, err := httpClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to make a %s request to url '%s' for host '%s': %w", method, requestURL, host, err)
+			failed++
+			continue
+		}
+		response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			lastErr = fmt.Errorf("unexpected response for a %s request to url '%s' for host '%s' - %s", method, requestURL, host, response.Status)
+			failed++
+			continue
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to purge %d/%d url prefixes for '%s': %w", failed, len(cfg.URLPrefixes), irodsPath, lastErr)
+	}
+
+	return nil
+}
+
+// applyAuth sets basic auth or a custom auth header on req, according to cfg.
+func applyAuth(req *http.Request, cfg CacheBackendConfig) {
+	if len(cfg.AuthHeader) > 0 {
+		req.Header.Set(cfg.AuthHeader, cfg.AuthValue)
+		return
+	}
+
+	if len(cfg.AuthUsername) > 0 {
+		req.SetBasicAuth(cfg.AuthUsername, cfg.AuthPassword)
+	}
+}
+
+// varnishPurger purges a Varnish-fronted cache with an HTTP PURGE request.
+// This is purgeman's original (and default) behavior.
+type varnishPurger struct {
+	name       string
+	config     CacheBackendConfig
+	httpClient *http.Client
+}
+
+func (p *varnishPurger) Name() string {
+	return p.name
+}
+
+func (p *varnishPurger) Purge(eventType string, irodsPath string, uuid string) error {
+	method := p.config.Method
+	if len(method) == 0 {
+		method = "PURGE"
+	}
+
+	return purgeURLPrefixes(p.httpClient, p.config, method, irodsPath)
+}
+
+// nginxPurger purges an nginx proxy_cache_purge-style cache. The default
+// method is "PURGE", matching the common ngx_cache_purge module convention,
+// but operators can set Method to e.g. "GET" for modules that purge via a
+// query-string convention instead.
+type nginxPurger struct {
+	name       string
+	config     CacheBackendConfig
+	httpClient *http.Client
+}
+
+func (p *nginxPurger) Name() string {
+	return p.name
+}
+
+func (p *nginxPurger) Purge(eventType string, irodsPath string, uuid string) error {
+	method := p.config.Method
+	if len(method) == 0 {
+		method = "PURGE"
+	}
+
+	return purgeURLPrefixes(p.httpClient, p.config, method, irodsPath)
+}
+
+// webhookEvent is the JSON body posted by webhookPurger.
+type webhookEvent struct {
+	Path  string `json:"path"`
+	UUID  string `json:"uuid"`
+	Event string `json:"event"`
+}
+
+// webhookPurger POSTs a JSON purge event to an arbitrary URL, letting
+// operators forward iRODS events to caches purgeman has no built-in support
+// for.
+type webhookPurger struct {
+	name       string
+	config     CacheBackendConfig
+	httpClient *http.Client
+}
+
+func (p *webhookPurger) Name() string {
+	return p.name
+}
+
+func (p *webhookPurger) Purge(eventType string, irodsPath string, uuid string) error {
+	if len(p.config.WebhookURL) == 0 {
+		return fmt.Errorf("webhook backend '%s' has no webhook_url configured", p.name)
+	}
+
+	if len(eventType) == 0 {
+		eventType = "purge"
+	}
+
+	body, err := json.Marshal(webhookEvent{Path: irodsPath, UUID: uuid, Event: eventType})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event for '%s': %w", p.name, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create a webhook request to '%s': %w", p.config.WebhookURL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	applyAuth(req, p.config)
+
+	response, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post a webhook event to '%s': %w", p.config.WebhookURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response for a webhook event to '%s' - %s", p.config.WebhookURL, response.Status)
+	}
+
+	return nil
+}
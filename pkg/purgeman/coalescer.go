@@ -0,0 +1,228 @@
+package purgeman
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPurgeDebounce    = 500 * time.Millisecond
+	defaultPurgeMaxDelay    = 5 * time.Second
+	defaultPurgeQueueSize   = 1024
+	defaultPurgeConcurrency = 16
+)
+
+// CoalescerConfig configures the debounce window and worker pool that sit in
+// front of a CachePurger.
+type CoalescerConfig struct {
+	// PurgeDebounce is how long to wait for more events on the same path
+	// before issuing the purge. Defaults to 500ms.
+	PurgeDebounce time.Duration `yaml:"purge_debounce"`
+	// PurgeMaxDelay bounds how long a steady stream of events on the same
+	// path can keep postponing its purge. Defaults to 5s.
+	PurgeMaxDelay time.Duration `yaml:"purge_max_delay"`
+	// PurgeQueueSize bounds how many distinct pending purges may be queued
+	// for the worker pool at once. Extra purges are dropped and counted.
+	// Defaults to 1024.
+	PurgeQueueSize int `yaml:"purge_queue_size"`
+	// PurgeConcurrency is the number of workers draining the purge queue.
+	// Defaults to 16.
+	PurgeConcurrency int `yaml:"purge_concurrency"`
+}
+
+// withDefaults returns a copy of c with zero fields replaced by defaults.
+func (c CoalescerConfig) withDefaults() CoalescerConfig {
+	if c.PurgeDebounce <= 0 {
+		c.PurgeDebounce = defaultPurgeDebounce
+	}
+	if c.PurgeMaxDelay <= 0 {
+		c.PurgeMaxDelay = defaultPurgeMaxDelay
+	}
+	if c.PurgeQueueSize <= 0 {
+		c.PurgeQueueSize = defaultPurgeQueueSize
+	}
+	if c.PurgeConcurrency <= 0 {
+		c.PurgeConcurrency = defaultPurgeConcurrency
+	}
+
+	return c
+}
+
+// purgeJob is one debounced purge waiting for a worker.
+type purgeJob struct {
+	key       string
+	eventType string
+	path      string
+	uuid      string
+	firstSeen time.Time
+}
+
+// pendingPurge tracks a debounce timer in flight for a key.
+type pendingPurge struct {
+	timer     *time.Timer
+	firstSeen time.Time
+}
+
+// CoalescingPurger wraps a CachePurger with a short debounce window so
+// repeated events for the same path (and event type) within the window
+// collapse into a single underlying Purge call, and bounds concurrency with
+// a worker pool so a burst of distinct paths can't spawn unbounded
+// goroutines. It can wrap any CachePurger uniformly.
+type CoalescingPurger struct {
+	inner   CachePurger
+	config  CoalescerConfig
+	metrics *Metrics
+
+	mutex   sync.Mutex
+	pending map[string]*pendingPurge
+
+	jobs   chan purgeJob
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewCoalescingPurger wraps inner with debouncing and a worker pool, and
+// starts the worker pool. metrics may be nil, in which case no metrics are
+// recorded.
+func NewCoalescingPurger(inner CachePurger, config CoalescerConfig, metrics *Metrics) *CoalescingPurger {
+	config = config.withDefaults()
+
+	cp := &CoalescingPurger{
+		inner:   inner,
+		config:  config,
+		metrics: metrics,
+		pending: make(map[string]*pendingPurge),
+		jobs:    make(chan purgeJob, config.PurgeQueueSize),
+	}
+
+	for i := 0; i < config.PurgeConcurrency; i++ {
+		cp.wg.Add(1)
+		go cp.worker()
+	}
+
+	return cp
+}
+
+// Name identifies the wrapped backend for logging and metrics.
+func (cp *CoalescingPurger) Name() string {
+	return cp.inner.Name()
+}
+
+// Purge satisfies CachePurger by submitting path for a debounced purge.
+func (cp *CoalescingPurger) Purge(eventType string, path string, uuid string) error {
+	cp.Submit(eventType, path, uuid)
+	return nil
+}
+
+// Submit schedules path (keyed by eventType+path) for a debounced purge. It
+// never blocks: if the queue is full when the debounce timer fires, the
+// purge is dropped and counted rather than backing up the caller.
+func (cp *CoalescingPurger) Submit(eventType string, path string, uuid string) {
+	key := eventType + "\x00" + path
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if existing, found := cp.pending[key]; found {
+		if cp.metrics != nil {
+			cp.metrics.PurgeCoalescedTotal.WithLabelValues(cp.Name()).Inc()
+		}
+
+		if time.Since(existing.firstSeen) >= cp.config.PurgeMaxDelay {
+			// already past the max delay budget; let the in-flight timer
+			// fire on its own rather than postponing further
+			return
+		}
+
+		existing.timer.Reset(cp.config.PurgeDebounce)
+		return
+	}
+
+	firstSeen := time.Now()
+	cp.pending[key] = &pendingPurge{
+		firstSeen: firstSeen,
+		timer: time.AfterFunc(cp.config.PurgeDebounce, func() {
+			cp.fire(key, eventType, path, uuid, firstSeen)
+		}),
+	}
+}
+
+// fire removes key from the pending set and enqueues path for a worker. It is
+// a no-op once Close has run: Close stops every pending timer under the same
+// mutex, but a timer already mid-fire when Close is called could otherwise
+// race past that and send on the now-closed jobs channel, which panics. The
+// closed check and the (non-blocking) send both happen under the mutex.
+func (cp *CoalescingPurger) fire(key string, eventType string, path string, uuid string, firstSeen time.Time) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	delete(cp.pending, key)
+	if cp.closed {
+		return
+	}
+
+	select {
+	case cp.jobs <- purgeJob{key: key, eventType: eventType, path: path, uuid: uuid, firstSeen: firstSeen}:
+	default:
+		if cp.metrics != nil {
+			cp.metrics.PurgeDroppedTotal.WithLabelValues(cp.Name()).Inc()
+		}
+		log.WithFields(log.Fields{
+			"package":  "purgeman",
+			"function": "CoalescingPurger.fire",
+			"backend":  cp.Name(),
+		}).Warnf("Purge queue is full, dropping purge for '%s'", path)
+	}
+}
+
+// worker drains jobs and calls the wrapped purger.
+func (cp *CoalescingPurger) worker() {
+	defer cp.wg.Done()
+
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "CoalescingPurger.worker",
+		"backend":  cp.Name(),
+	})
+
+	for job := range cp.jobs {
+		if cp.metrics != nil {
+			cp.metrics.EventToPurgeLatency.Observe(time.Since(job.firstSeen).Seconds())
+		}
+
+		if err := cp.inner.Purge(job.eventType, job.path, job.uuid); err != nil {
+			logger.WithError(err).Errorf("Failed to purge '%s' on backend '%s'", job.path, cp.Name())
+			continue
+		}
+	}
+}
+
+// Health delegates to the wrapped backend if it implements health reporting
+// (a RetryingPurger reports HealthError while its circuit breaker is open),
+// and reports HealthOK otherwise.
+func (cp *CoalescingPurger) Health() HealthStatus {
+	if hc, ok := cp.inner.(interface{ Health() HealthStatus }); ok {
+		return hc.Health()
+	}
+
+	return HealthOK()
+}
+
+// Close stops every pending debounce timer, stops accepting new jobs and
+// waits for in-flight workers to drain. Stopping the timers under the same
+// mutex fire uses to check cp.closed ensures none of them can enqueue a job
+// after the jobs channel is closed below.
+func (cp *CoalescingPurger) Close() {
+	cp.mutex.Lock()
+	cp.closed = true
+	for key, pending := range cp.pending {
+		pending.timer.Stop()
+		delete(cp.pending, key)
+	}
+	cp.mutex.Unlock()
+
+	close(cp.jobs)
+	cp.wg.Wait()
+}
@@ -0,0 +1,98 @@
+package purgeman
+
+// Config is a configuration for the purgeman service
+type Config struct {
+	IRODSHost     string `yaml:"irods_host"`
+	IRODSPort     int    `yaml:"irods_port"`
+	IRODSZone     string `yaml:"irods_zone"`
+	IRODSUsername string `yaml:"irods_username"`
+	IRODSPassword string `yaml:"irods_password"`
+
+	AMQPHost     string `yaml:"amqp_host"`
+	AMQPPort     int    `yaml:"amqp_port"`
+	AMQPUsername string `yaml:"amqp_username"`
+	AMQPPassword string `yaml:"amqp_password"`
+	AMQPVHost    string `yaml:"amqp_vhost"`
+	AMQPExchange string `yaml:"amqp_exchange"`
+
+	// VarnishURLPrefixes and VarnishHostsOverride are deprecated in favor of
+	// CacheBackends, but are still honored for backward compatibility - they
+	// are translated into a single "varnish" backend at Connect() time.
+	VarnishURLPrefixes   []string `yaml:"varnish_url_prefixes"`
+	VarnishHostsOverride []string `yaml:"varnish_hosts_override"`
+
+	// CacheBackends lists the cache backends that should be purged whenever
+	// an iRODS fs event is observed. Each block's Type selects the
+	// implementation (e.g. "varnish", "nginx", "webhook").
+	CacheBackends []CacheBackendConfig `yaml:"cache_backends"`
+
+	// WebsocketEventSources lists additional Arvados-style websocket log
+	// streams to monitor for fs events, alongside the AMQP queue configured
+	// above. Events from every source are fed into the same purge
+	// pipeline.
+	WebsocketEventSources []WebsocketEventSourceConfig `yaml:"websocket_event_sources"`
+
+	// Coalescer configures the debounce window and worker pool placed in
+	// front of every cache backend. Zero fields fall back to defaults.
+	Coalescer CoalescerConfig `yaml:"coalescer"`
+
+	// ListenAddr, if set, starts an embedded HTTP server exposing
+	// /healthz, /readyz and /metrics on this address (e.g. ":9090").
+	// Leave empty to disable the status server.
+	ListenAddr string `yaml:"listen_addr"`
+
+	// UUIDResolver configures the UUID->path lookup cache and batching used
+	// when an fs event doesn't carry a path directly.
+	UUIDResolver UUIDResolverConfig `yaml:"uuid_resolver"`
+}
+
+// CacheBackendConfig describes a single configured cache backend. Fields
+// that don't apply to a given Type are simply left empty.
+type CacheBackendConfig struct {
+	// Name uniquely identifies the backend for logging and metrics. If
+	// empty, Type is used.
+	Name string `yaml:"name"`
+	// Type selects the CachePurger implementation ("varnish", "nginx" or
+	// "webhook").
+	Type string `yaml:"type"`
+
+	// URLPrefixes are the cache-fronted URL prefixes to purge. Used by the
+	// varnish and nginx backends.
+	URLPrefixes []string `yaml:"url_prefixes"`
+	// HostsOverride optionally overrides the Host header sent with the purge
+	// request, indexed the same way as URLPrefixes. The TLS server name is
+	// still taken from the URL prefix.
+	HostsOverride []string `yaml:"hosts_override"`
+	// Method is the HTTP method used to purge. Defaults to "PURGE" for both
+	// the varnish and nginx backends, matching the ngx_cache_purge
+	// convention; set it explicitly (e.g. "GET") for nginx setups that purge
+	// via a query-string convention instead.
+	Method string `yaml:"method"`
+
+	// AuthUsername/AuthPassword set HTTP basic auth on the purge request.
+	AuthUsername string `yaml:"auth_username"`
+	AuthPassword string `yaml:"auth_password"`
+	// AuthHeader/AuthValue set an arbitrary auth header instead of basic
+	// auth (e.g. "X-Purge-Token").
+	AuthHeader string `yaml:"auth_header"`
+	AuthValue  string `yaml:"auth_value"`
+
+	// WebhookURL is the endpoint the webhook backend POSTs JSON events to.
+	WebhookURL string `yaml:"webhook_url"`
+
+	// TimeoutSeconds bounds how long a single purge request may take.
+	// Defaults to 10 seconds if unset.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// TLSInsecureSkipVerify disables TLS certificate verification for this
+	// backend. Only intended for testing against self-signed caches.
+	TLSInsecureSkipVerify bool `yaml:"tls_insecure_skip_verify"`
+	// MaxIdleConnsPerHost bounds the backend's HTTP connection pool.
+	// Defaults to 16.
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+
+	// Retry configures the exponential-backoff retry policy applied to
+	// purges against this backend.
+	Retry RetryConfig `yaml:"retry"`
+	// Breaker configures this backend's circuit breaker.
+	Breaker BreakerConfig `yaml:"breaker"`
+}
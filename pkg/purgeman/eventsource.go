@@ -0,0 +1,22 @@
+package purgeman
+
+// EventHandler is called for every fs event observed by an EventSource. It
+// mirrors PurgemanService.fsEventHandler's signature: eventType names the
+// kind of change (e.g. "put", "rm", "mv"), path is the iRODS path if the
+// event carried one, and uuid is the ipc_UUID metadata value to resolve to
+// a path when it didn't.
+type EventHandler func(eventType string, path string, uuid string)
+
+// EventSource is anything that can be monitored for iRODS fs change
+// notifications and dispatch them through an EventHandler. IRODSMessageQueueConnection
+// (AMQP) and WebsocketEventSource both satisfy this so PurgemanService can
+// fan events from any number of sources into the same purge pipeline.
+type EventSource interface {
+	// Name identifies the source for logging.
+	Name() string
+	// Monitor blocks, delivering events to handler until the source is
+	// closed or an unrecoverable error occurs.
+	Monitor(handler EventHandler) error
+	// Close stops monitoring and releases any underlying connection.
+	Close() error
+}
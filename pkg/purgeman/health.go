@@ -0,0 +1,77 @@
+package purgeman
+
+import "sync"
+
+// HealthStatus is the result of a single named health check, modeled after
+// Arvados' health aggregator: every check reports either OK or Error with a
+// human-readable message.
+type HealthStatus struct {
+	Health string `json:"health"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthOK builds a passing HealthStatus.
+func HealthOK() HealthStatus {
+	return HealthStatus{Health: "OK"}
+}
+
+// HealthError builds a failing HealthStatus.
+func HealthError(err error) HealthStatus {
+	return HealthStatus{Health: "ERROR", Error: err.Error()}
+}
+
+// HealthCheck reports the current status of one subsystem (the iRODS
+// client, an event source, a cache backend, ...).
+type HealthCheck func() HealthStatus
+
+// HealthReport is the aggregated JSON document returned by /healthz: an
+// overall status plus every named check that went into it.
+type HealthReport struct {
+	Health string                  `json:"health"`
+	Checks map[string]HealthStatus `json:"checks"`
+}
+
+// HealthAggregator collects named HealthChecks and reports them as a single
+// document, mirroring Arvados' health aggregator pattern.
+type HealthAggregator struct {
+	mutex  sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+// NewHealthAggregator creates an empty HealthAggregator.
+func NewHealthAggregator() *HealthAggregator {
+	return &HealthAggregator{
+		checks: make(map[string]HealthCheck),
+	}
+}
+
+// Register adds or replaces the named check.
+func (h *HealthAggregator) Register(name string, check HealthCheck) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.checks[name] = check
+}
+
+// Report runs every registered check and aggregates the results. The
+// overall health is OK only if every individual check is OK.
+func (h *HealthAggregator) Report() HealthReport {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	report := HealthReport{
+		Health: "OK",
+		Checks: make(map[string]HealthStatus, len(h.checks)),
+	}
+
+	for name, check := range h.checks {
+		status := check()
+		report.Checks[name] = status
+
+		if status.Health != "OK" {
+			report.Health = "ERROR"
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,153 @@
+package purgeman
+
+import (
+	"encoding/json"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// IRODSMessageQueueConfig is a configuration for connecting to the iRODS
+// AMQP message queue that carries fs change notifications.
+type IRODSMessageQueueConfig struct {
+	Username string
+	Password string
+	Host     string
+	Port     int
+	VHost    string
+	Exchange string
+}
+
+// irodsFSEvent is the JSON body of an iRODS AMQP fs change notification.
+type irodsFSEvent struct {
+	EventType string `json:"event_type"`
+	Path      string `json:"path"`
+	UUID      string `json:"uuid"`
+}
+
+// IRODSMessageQueueConnection is a connection to the iRODS AMQP exchange
+// that carries fs change notifications. It satisfies EventSource.
+type IRODSMessageQueueConnection struct {
+	config  *IRODSMessageQueueConfig
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   amqp.Queue
+}
+
+// ConnectIRODSMessageQueue connects to the iRODS AMQP exchange described by
+// config and declares an exclusive queue bound to it.
+func ConnectIRODSMessageQueue(config *IRODSMessageQueueConfig) (*IRODSMessageQueueConnection, error) {
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "ConnectIRODSMessageQueue",
+	})
+
+	url := fmt.Sprintf("amqp://%s:%s@%s:%d/%s", config.Username, config.Password, config.Host, config.Port, config.VHost)
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		logger.WithError(err).Error("Failed to connect to the AMQP broker")
+		return nil, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		logger.WithError(err).Error("Failed to open an AMQP channel")
+		defer conn.Close()
+		return nil, err
+	}
+
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		logger.WithError(err).Error("Failed to declare an AMQP queue")
+		defer channel.Close()
+		defer conn.Close()
+		return nil, err
+	}
+
+	if err := channel.QueueBind(queue.Name, "#", config.Exchange, false, nil); err != nil {
+		logger.WithError(err).Error("Failed to bind the AMQP queue to the exchange")
+		defer channel.Close()
+		defer conn.Close()
+		return nil, err
+	}
+
+	return &IRODSMessageQueueConnection{
+		config:  config,
+		conn:    conn,
+		channel: channel,
+		queue:   queue,
+	}, nil
+}
+
+// Name identifies the source for logging.
+func (mq *IRODSMessageQueueConnection) Name() string {
+	return "amqp"
+}
+
+// Disconnect closes the AMQP channel and connection.
+func (mq *IRODSMessageQueueConnection) Disconnect() {
+	if mq.channel != nil {
+		mq.channel.Close()
+	}
+
+	if mq.conn != nil {
+		mq.conn.Close()
+	}
+}
+
+// MonitorFSChanges consumes fs change notifications off the queue and calls
+// handler for each one. It blocks until the connection is closed or
+// delivery of messages stops.
+func (mq *IRODSMessageQueueConnection) MonitorFSChanges(handler func(eventtype string, path string, uuid string)) error {
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "IRODSMessageQueueConnection.MonitorFSChanges",
+	})
+
+	deliveries, err := mq.channel.Consume(mq.queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		logger.WithError(err).Error("Failed to start consuming the AMQP queue")
+		return err
+	}
+
+	for delivery := range deliveries {
+		event := irodsFSEvent{}
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			logger.WithError(err).Error("Failed to unmarshal an AMQP fs event")
+			continue
+		}
+
+		handler(event.EventType, event.Path, event.UUID)
+	}
+
+	return nil
+}
+
+// Monitor satisfies EventSource by adapting the handler signature.
+func (mq *IRODSMessageQueueConnection) Monitor(handler EventHandler) error {
+	return mq.MonitorFSChanges(func(eventtype string, path string, uuid string) {
+		handler(eventtype, path, uuid)
+	})
+}
+
+// Close satisfies EventSource.
+func (mq *IRODSMessageQueueConnection) Close() error {
+	mq.Disconnect()
+	return nil
+}
+
+// Health reports HealthError if the underlying AMQP connection or channel
+// has gone away, so a dead broker link actually fails /healthz instead of
+// always reporting OK.
+func (mq *IRODSMessageQueueConnection) Health() HealthStatus {
+	if mq.conn == nil || mq.conn.IsClosed() {
+		return HealthError(fmt.Errorf("amqp connection to '%s' is closed", mq.config.Host))
+	}
+
+	if mq.channel == nil || mq.channel.IsClosed() {
+		return HealthError(fmt.Errorf("amqp channel to '%s' is closed", mq.config.Host))
+	}
+
+	return HealthOK()
+}
@@ -0,0 +1,138 @@
+package purgeman
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors purgeman reports on /metrics. It
+// owns its own Registry rather than using the global default one, so
+// multiple PurgemanService instances (e.g. in tests) don't collide.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	EventsTotal             *prometheus.CounterVec
+	PurgesTotal             *prometheus.CounterVec
+	UUIDLookupFailuresTotal prometheus.Counter
+
+	PurgeCoalescedTotal *prometheus.CounterVec
+	PurgeDroppedTotal   *prometheus.CounterVec
+
+	PurgeAttemptsTotal      *prometheus.CounterVec
+	PurgeRetriesTotal       *prometheus.CounterVec
+	PurgeRejectedTotal      *prometheus.CounterVec
+	BreakerTransitionsTotal *prometheus.CounterVec
+
+	PurgeDuration       *prometheus.HistogramVec
+	EventToPurgeLatency prometheus.Histogram
+
+	AMQPConnected  prometheus.Gauge
+	InFlightPurges prometheus.Gauge
+}
+
+// NewMetrics creates and registers purgeman's Prometheus collectors.
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+
+	m := &Metrics{
+		Registry: registry,
+
+		EventsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_events_total",
+			Help: "Total number of fs events observed, by event type.",
+		}, []string{"event_type"}),
+
+		PurgesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purges_total",
+			Help: "Total number of purges (each counted once, after any retries), by backend and outcome.",
+		}, []string{"backend", "status"}),
+
+		UUIDLookupFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "purgeman_uuid_lookup_failures_total",
+			Help: "Total number of UUID-to-path lookups that did not resolve to a path (zero or more than one match, or a failed query).",
+		}),
+
+		PurgeCoalescedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purge_coalesced_total",
+			Help: "Total number of events that were coalesced into an already-pending debounced purge, by backend.",
+		}, []string{"backend"}),
+
+		PurgeDroppedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purge_dropped_total",
+			Help: "Total number of debounced purges dropped because the worker queue was full, by backend.",
+		}, []string{"backend"}),
+
+		PurgeAttemptsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purge_attempts_total",
+			Help: "Total number of purge attempts against a backend, including retries.",
+		}, []string{"backend"}),
+
+		PurgeRetriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purge_retries_total",
+			Help: "Total number of retried purge attempts against a backend.",
+		}, []string{"backend"}),
+
+		PurgeRejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_purge_rejected_total",
+			Help: "Total number of purges skipped because a backend's circuit breaker was open.",
+		}, []string{"backend"}),
+
+		BreakerTransitionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "purgeman_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions, by backend.",
+		}, []string{"backend"}),
+
+		PurgeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "purgeman_purge_duration_seconds",
+			Help:    "Duration of a purge against a backend, including retries and backoff delays.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"backend"}),
+
+		EventToPurgeLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "purgeman_event_to_purge_latency_seconds",
+			Help:    "Latency from observing an fs event to issuing its purge, including debounce wait.",
+			Buckets: prometheus.DefBuckets,
+		}),
+
+		AMQPConnected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "purgeman_amqp_connected",
+			Help: "Whether the AMQP connection is currently up (1) or down (0).",
+		}),
+
+		InFlightPurges: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "purgeman_in_flight_purges",
+			Help: "Number of purge requests currently in flight across all backends.",
+		}),
+	}
+
+	registry.MustRegister(
+		m.EventsTotal,
+		m.PurgesTotal,
+		m.UUIDLookupFailuresTotal,
+		m.PurgeCoalescedTotal,
+		m.PurgeDroppedTotal,
+		m.PurgeAttemptsTotal,
+		m.PurgeRetriesTotal,
+		m.PurgeRejectedTotal,
+		m.BreakerTransitionsTotal,
+		m.PurgeDuration,
+		m.EventToPurgeLatency,
+		m.AMQPConnected,
+		m.InFlightPurges,
+	)
+
+	return m
+}
+
+// observePurge records the final outcome and total duration of one purge
+// against backend, across all of its retry attempts.
+func (m *Metrics) observePurge(backend string, started time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+
+	m.PurgesTotal.WithLabelValues(backend, status).Inc()
+	m.PurgeDuration.WithLabelValues(backend).Observe(time.Since(started).Seconds())
+}
@@ -0,0 +1,307 @@
+package purgeman
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialDelay   = 200 * time.Millisecond
+	defaultRetryMultiplier     = 2.0
+	defaultRetryMaxElapsedTime = 30 * time.Second
+
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// RetryConfig configures the retry policy wrapped around a single PURGE
+// request.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Defaults to 3.
+	MaxAttempts int `yaml:"retry_max_attempts"`
+	// InitialDelay is the delay before the first retry. Defaults to 200ms.
+	InitialDelay time.Duration `yaml:"retry_initial_delay"`
+	// Multiplier scales the delay after each attempt. Defaults to 2.0.
+	Multiplier float64 `yaml:"retry_multiplier"`
+	// MaxElapsedTime bounds the total time spent retrying, across all
+	// attempts. Defaults to 30s.
+	MaxElapsedTime time.Duration `yaml:"retry_max_elapsed_time"`
+	// Jitter adds up to +/-25% random jitter to each delay to avoid
+	// thundering-herd retries across many paths.
+	Jitter bool `yaml:"retry_jitter"`
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if c.InitialDelay <= 0 {
+		c.InitialDelay = defaultRetryInitialDelay
+	}
+	if c.Multiplier <= 0 {
+		c.Multiplier = defaultRetryMultiplier
+	}
+	if c.MaxElapsedTime <= 0 {
+		c.MaxElapsedTime = defaultRetryMaxElapsedTime
+	}
+
+	return c
+}
+
+// BreakerConfig configures the per-backend circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker. Defaults to 5.
+	FailureThreshold int `yaml:"breaker_failure_threshold"`
+	// Cooldown is how long the breaker stays open before half-opening to
+	// let a single probe request through. Defaults to 30s.
+	Cooldown time.Duration `yaml:"breaker_cooldown"`
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = defaultBreakerCooldown
+	}
+
+	return c
+}
+
+// breakerState is the state of a CircuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker opens after FailureThreshold consecutive failures,
+// short-circuiting further requests until Cooldown elapses, then lets a
+// single probe request through (half-open) to decide whether to close again.
+type CircuitBreaker struct {
+	name    string
+	config  BreakerConfig
+	metrics *Metrics
+
+	mutex            sync.Mutex
+	state            breakerState
+	consecutiveFails int
+	openedAt         time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker for the backend called
+// name. metrics may be nil, in which case no metrics are recorded.
+func NewCircuitBreaker(name string, config BreakerConfig, metrics *Metrics) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:    name,
+		config:  config.withDefaults(),
+		metrics: metrics,
+		state:   breakerClosed,
+	}
+}
+
+// recordTransition counts a state transition. Callers must hold cb.mutex.
+func (cb *CircuitBreaker) recordTransition() {
+	if cb.metrics != nil {
+		cb.metrics.BreakerTransitionsTotal.WithLabelValues(cb.name).Inc()
+	}
+}
+
+// Allow reports whether a request may proceed right now. When the breaker is
+// open past its cooldown it transitions to half-open and allows exactly one
+// probe request through.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case breakerClosed:
+		return true
+	case breakerHalfOpen:
+		return false
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.config.Cooldown {
+			return false
+		}
+
+		cb.state = breakerHalfOpen
+		cb.recordTransition()
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the breaker and resets its failure count.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state != breakerClosed {
+		cb.recordTransition()
+	}
+
+	cb.state = breakerClosed
+	cb.consecutiveFails = 0
+}
+
+// RecordFailure counts a failure, opening the breaker once the threshold is
+// reached (or immediately, if a half-open probe itself failed).
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == breakerHalfOpen {
+		cb.open()
+		return
+	}
+
+	cb.consecutiveFails++
+	if cb.consecutiveFails >= cb.config.FailureThreshold {
+		cb.open()
+	}
+}
+
+// open transitions the breaker to open. Callers must hold cb.mutex.
+func (cb *CircuitBreaker) open() {
+	cb.state = breakerOpen
+	cb.openedAt = time.Now()
+	cb.recordTransition()
+}
+
+// State returns the breaker's current state, for metrics reporting.
+func (cb *CircuitBreaker) State() string {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	return cb.state.String()
+}
+
+// RetryingPurger wraps a CachePurger with exponential-backoff retries and a
+// per-backend CircuitBreaker, so a transient failure (e.g. a Varnish
+// restart) doesn't silently leave stale content cached.
+type RetryingPurger struct {
+	inner   CachePurger
+	retry   RetryConfig
+	breaker *CircuitBreaker
+	metrics *Metrics
+}
+
+// NewRetryingPurger wraps inner with retryConfig and a fresh CircuitBreaker
+// built from breakerConfig. metrics may be nil, in which case no metrics are
+// recorded.
+func NewRetryingPurger(inner CachePurger, retryConfig RetryConfig, breakerConfig BreakerConfig, metrics *Metrics) *RetryingPurger {
+	return &RetryingPurger{
+		inner:   inner,
+		retry:   retryConfig.withDefaults(),
+		breaker: NewCircuitBreaker(inner.Name(), breakerConfig, metrics),
+		metrics: metrics,
+	}
+}
+
+// Name identifies the wrapped backend for logging and metrics.
+func (rp *RetryingPurger) Name() string {
+	return rp.inner.Name()
+}
+
+// Purge retries the wrapped Purge call with exponential backoff, short-
+// circuiting through the circuit breaker when the backend looks unhealthy.
+func (rp *RetryingPurger) Purge(eventType string, path string, uuid string) error {
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "RetryingPurger.Purge",
+		"backend":  rp.Name(),
+	})
+
+	start := time.Now()
+	var finalErr error
+	if rp.metrics != nil {
+		rp.metrics.InFlightPurges.Inc()
+		defer func() {
+			rp.metrics.InFlightPurges.Dec()
+			rp.metrics.observePurge(rp.Name(), start, finalErr)
+		}()
+	}
+
+	if !rp.breaker.Allow() {
+		if rp.metrics != nil {
+			rp.metrics.PurgeRejectedTotal.WithLabelValues(rp.Name()).Inc()
+		}
+		finalErr = fmt.Errorf("circuit breaker for backend '%s' is open, skipping purge of '%s'", rp.Name(), path)
+		return finalErr
+	}
+
+	delay := rp.retry.InitialDelay
+	attempts := 0
+
+	for attempt := 1; attempt <= rp.retry.MaxAttempts; attempt++ {
+		attempts = attempt
+		if rp.metrics != nil {
+			rp.metrics.PurgeAttemptsTotal.WithLabelValues(rp.Name()).Inc()
+		}
+
+		finalErr = rp.inner.Purge(eventType, path, uuid)
+		if finalErr == nil {
+			rp.breaker.RecordSuccess()
+			return nil
+		}
+
+		logger.WithError(finalErr).Warnf("Attempt %d/%d to purge '%s' failed", attempt, rp.retry.MaxAttempts, path)
+
+		if attempt == rp.retry.MaxAttempts || time.Since(start) >= rp.retry.MaxElapsedTime {
+			break
+		}
+
+		if rp.metrics != nil {
+			rp.metrics.PurgeRetriesTotal.WithLabelValues(rp.Name()).Inc()
+		}
+		time.Sleep(rp.jitteredDelay(delay))
+		delay = time.Duration(float64(delay) * rp.retry.Multiplier)
+	}
+
+	rp.breaker.RecordFailure()
+	finalErr = fmt.Errorf("giving up purging '%s' on backend '%s' after %d attempts: %w", path, rp.Name(), attempts, finalErr)
+	return finalErr
+}
+
+// Health reports HealthError when the circuit breaker is open.
+func (rp *RetryingPurger) Health() HealthStatus {
+	if rp.breaker.State() == breakerOpen.String() {
+		return HealthError(fmt.Errorf("circuit breaker for backend '%s' is open", rp.Name()))
+	}
+
+	return HealthOK()
+}
+
+// jitteredDelay applies up to +/-25% jitter to delay when enabled.
+func (rp *RetryingPurger) jitteredDelay(delay time.Duration) time.Duration {
+	if !rp.retry.Jitter || delay <= 0 {
+		return delay
+	}
+
+	spread := float64(delay) * 0.25
+	offset := (rand.Float64()*2 - 1) * spread //nolint:gosec
+	return time.Duration(float64(delay) + offset)
+}
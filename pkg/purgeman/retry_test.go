@@ -0,0 +1,93 @@
+package purgeman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	cooldown := 20 * time.Millisecond
+	breaker := func() *CircuitBreaker {
+		return NewCircuitBreaker("test", BreakerConfig{FailureThreshold: 3, Cooldown: cooldown}, nil)
+	}
+
+	t.Run("stays closed below the failure threshold", func(t *testing.T) {
+		cb := breaker()
+
+		cb.RecordFailure()
+		cb.RecordFailure()
+
+		if got := cb.State(); got != "closed" {
+			t.Fatalf("State() = %q, want closed", got)
+		}
+		if !cb.Allow() {
+			t.Fatal("Allow() = false, want true while closed")
+		}
+	})
+
+	t.Run("opens at the failure threshold and rejects until cooldown elapses", func(t *testing.T) {
+		cb := breaker()
+
+		cb.RecordFailure()
+		cb.RecordFailure()
+		cb.RecordFailure()
+
+		if got := cb.State(); got != "open" {
+			t.Fatalf("State() = %q, want open", got)
+		}
+		if cb.Allow() {
+			t.Fatal("Allow() = true, want false immediately after opening")
+		}
+
+		time.Sleep(cooldown + 5*time.Millisecond)
+
+		if !cb.Allow() {
+			t.Fatal("Allow() = false, want true once cooldown has elapsed")
+		}
+		if got := cb.State(); got != "half-open" {
+			t.Fatalf("State() = %q, want half-open after the cooldown probe is let through", got)
+		}
+	})
+
+	t.Run("half-open probe success closes the breaker and resets the failure count", func(t *testing.T) {
+		cb := breaker()
+
+		cb.RecordFailure()
+		cb.RecordFailure()
+		cb.RecordFailure()
+		time.Sleep(cooldown + 5*time.Millisecond)
+		cb.Allow() // transitions to half-open
+
+		cb.RecordSuccess()
+
+		if got := cb.State(); got != "closed" {
+			t.Fatalf("State() = %q, want closed after a successful probe", got)
+		}
+
+		// consecutiveFails must have reset: two failures alone shouldn't reopen it
+		cb.RecordFailure()
+		cb.RecordFailure()
+		if got := cb.State(); got != "closed" {
+			t.Fatalf("State() = %q, want closed (failure count should have reset on success)", got)
+		}
+	})
+
+	t.Run("half-open probe failure reopens the breaker immediately", func(t *testing.T) {
+		cb := breaker()
+
+		cb.RecordFailure()
+		cb.RecordFailure()
+		cb.RecordFailure()
+		time.Sleep(cooldown + 5*time.Millisecond)
+		cb.Allow() // transitions to half-open
+
+		cb.RecordFailure()
+
+		if got := cb.State(); got != "open" {
+			t.Fatalf("State() = %q, want open after a failed probe", got)
+		}
+		if cb.Allow() {
+			t.Fatal("Allow() = true, want false immediately after reopening")
+		}
+	})
+}
@@ -1,27 +1,46 @@
 package purgeman
 
 import (
+	"fmt"
 	"net/http"
-	"net/url"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	irodsfs_client "github.com/cyverse/go-irodsclient/fs"
 	irodsfs_clienttype "github.com/cyverse/go-irodsclient/irods/types"
 	log "github.com/sirupsen/logrus"
 )
 
+// irodsHealthCheckProbeUUID is queried by the "irods" health check on every
+// /healthz call. It's shaped like a real ipc_UUID value but can never match
+// one, so the query is a pure connectivity probe.
+const irodsHealthCheckProbeUUID = "00000000-0000-0000-0000-000000000000"
+
 // PurgemanService is a service object
 type PurgemanService struct {
 	Config                 *Config
 	IRODSClient            *irodsfs_client.FileSystem
 	MessageQueueConnection *IRODSMessageQueueConnection
+	EventSources           []EventSource
+	CachePurgers           []*CoalescingPurger
+	UUIDResolver           *UUIDResolver
+
+	Metrics *Metrics
+	Health  *HealthAggregator
+
+	statusServer      *http.Server
+	connected         int32
+	firstEventSeen    int32
+	lastEventUnixNano int64
 }
 
 // NewPurgeman creates a new purgeman service
 func NewPurgeman(config *Config) (*PurgemanService, error) {
 	return &PurgemanService{
-		Config: config,
+		Config:  config,
+		Metrics: NewMetrics(),
+		Health:  NewHealthAggregator(),
 	}, nil
 }
 
@@ -46,6 +65,7 @@ func (svc *PurgemanService) Connect() error {
 	}
 
 	svc.IRODSClient = fsclient
+	svc.UUIDResolver = NewUUIDResolver(fsclient, svc.Config.UUIDResolver)
 
 	// connect to AMQP
 	mqConfig := IRODSMessageQueueConfig{
@@ -66,6 +86,77 @@ func (svc *PurgemanService) Connect() error {
 	}
 
 	svc.MessageQueueConnection = mqConn
+	svc.Metrics.AMQPConnected.Set(1)
+
+	eventSources := []EventSource{mqConn}
+	for _, wsConfig := range svc.Config.WebsocketEventSources {
+		eventSources = append(eventSources, NewWebsocketEventSource(wsConfig))
+	}
+
+	svc.EventSources = eventSources
+
+	svc.Health.Register("irods", func() HealthStatus {
+		if svc.IRODSClient == nil {
+			return HealthError(fmt.Errorf("not connected to iRODS"))
+		}
+
+		// a cheap metadata query against a uuid that can never match doubles
+		// as a connectivity probe: it only succeeds if the iRODS connection
+		// is actually alive, unlike a bare nil check
+		if _, err := svc.IRODSClient.SearchByMeta("ipc_UUID", irodsHealthCheckProbeUUID); err != nil {
+			return HealthError(fmt.Errorf("iRODS health probe failed: %w", err))
+		}
+
+		return HealthOK()
+	})
+	for _, source := range eventSources {
+		source := source
+		svc.Health.Register("event_source:"+source.Name(), func() HealthStatus {
+			if hc, ok := source.(interface{ Health() HealthStatus }); ok {
+				return hc.Health()
+			}
+			return HealthOK()
+		})
+	}
+
+	backendConfigs := svc.Config.CacheBackends
+	if len(svc.Config.VarnishURLPrefixes) > 0 {
+		// translate the legacy varnish-only fields into a backend block so
+		// existing configs keep working unmodified
+		backendConfigs = append([]CacheBackendConfig{
+			{
+				Name:          CacheBackendTypeVarnish,
+				Type:          CacheBackendTypeVarnish,
+				URLPrefixes:   svc.Config.VarnishURLPrefixes,
+				HostsOverride: svc.Config.VarnishHostsOverride,
+				AuthUsername:  svc.Config.IRODSUsername,
+				AuthPassword:  svc.Config.IRODSPassword,
+			},
+		}, backendConfigs...)
+	}
+
+	cachePurgers := make([]*CoalescingPurger, 0, len(backendConfigs))
+	for _, backendConfig := range backendConfigs {
+		purger, err := newCachePurger(backendConfig)
+		if err != nil {
+			logger.WithError(err).Errorf("Failed to create a cache backend '%s'", backendConfig.Type)
+			return err
+		}
+
+		retryingPurger := NewRetryingPurger(purger, backendConfig.Retry, backendConfig.Breaker, svc.Metrics)
+		cachePurgers = append(cachePurgers, NewCoalescingPurger(retryingPurger, svc.Config.Coalescer, svc.Metrics))
+	}
+
+	for _, purger := range cachePurgers {
+		purger := purger
+		svc.Health.Register("backend:"+purger.Name(), purger.Health)
+	}
+
+	svc.CachePurgers = cachePurgers
+
+	svc.startStatusServer()
+	atomic.StoreInt32(&svc.connected, 1)
+
 	return nil
 }
 
@@ -77,11 +168,34 @@ func (svc *PurgemanService) Start() error {
 
 	logger.Info("Starting the purgeman service")
 
-	// should not return
-	err := svc.MessageQueueConnection.MonitorFSChanges(svc.fsEventHandler)
-	if err != nil {
-		logger.Error(err)
-		defer svc.MessageQueueConnection.Disconnect()
+	// fan events from every configured source into the same purge pipeline;
+	// should not return until all sources stop
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(svc.EventSources))
+
+	for _, source := range svc.EventSources {
+		wg.Add(1)
+
+		go func(source EventSource) {
+			defer wg.Done()
+
+			logger.Infof("Monitoring event source '%s'", source.Name())
+			err := source.Monitor(svc.fsEventHandler)
+			if _, ok := source.(*IRODSMessageQueueConnection); ok {
+				// deliveries stop only once the AMQP channel or connection is gone
+				svc.Metrics.AMQPConnected.Set(0)
+			}
+			if err != nil {
+				logger.WithError(err).Errorf("Event source '%s' stopped with an error", source.Name())
+				errs <- err
+			}
+		}(source)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
 		defer svc.IRODSClient.Release()
 		return err
 	}
@@ -98,30 +212,38 @@ func (svc *PurgemanService) Destroy() {
 
 	logger.Info("Destroying the purgeman service")
 
+	svc.stopStatusServer()
+
 	if svc.IRODSClient != nil {
 		svc.IRODSClient.Release()
 		svc.IRODSClient = nil
 	}
 
-	if svc.MessageQueueConnection != nil {
-		svc.MessageQueueConnection.Disconnect()
-		svc.MessageQueueConnection = nil
+	for _, source := range svc.EventSources {
+		if err := source.Close(); err != nil {
+			logger.WithError(err).Warnf("Failed to close event source '%s'", source.Name())
+		}
+	}
+	svc.EventSources = nil
+	svc.MessageQueueConnection = nil
+	svc.Metrics.AMQPConnected.Set(0)
+	atomic.StoreInt32(&svc.connected, 0)
+
+	for _, purger := range svc.CachePurgers {
+		purger.Close()
 	}
+	svc.CachePurgers = nil
 }
 
-// fetchIRODSPath returns path from uuid
+// fetchIRODSPath returns path from uuid, via the UUID->path cache and
+// lookup batcher.
 func (svc *PurgemanService) fetchIRODSPath(uuid string) string {
-	entries, err := svc.IRODSClient.SearchByMeta("ipc_UUID", uuid)
-	if err == nil {
-		// only one entry must be found
-		if len(entries) == 1 {
-			// return full path of the data object or the collection
-			return entries[0].Path
-		}
+	path := svc.UUIDResolver.Resolve(uuid)
+	if len(path) == 0 {
+		svc.Metrics.UUIDLookupFailuresTotal.Inc()
 	}
 
-	// if we couldn't find, return empty string
-	return ""
+	return path
 }
 
 // fsEventHandler handles a fs event
@@ -131,6 +253,17 @@ func (svc *PurgemanService) fsEventHandler(eventtype string, path string, uuid s
 		"function": "PurgemanService.fsEventHandler",
 	})
 
+	svc.Metrics.EventsTotal.WithLabelValues(eventtype).Inc()
+	atomic.StoreInt64(&svc.lastEventUnixNano, time.Now().UnixNano())
+	atomic.StoreInt32(&svc.firstEventSeen, 1)
+
+	if len(uuid) > 0 && (eventtype == "rm" || eventtype == "mv") {
+		// the object's path is changing or going away; drop any cached
+		// resolution so a later event for the same uuid doesn't purge a
+		// stale path
+		svc.UUIDResolver.Invalidate(uuid)
+	}
+
 	iRODSPath := path
 	if len(path) == 0 && len(uuid) > 0 {
 		// conv uuid to path
@@ -138,77 +271,38 @@ func (svc *PurgemanService) fsEventHandler(eventtype string, path string, uuid s
 	}
 
 	logger.Infof("Reveiced a %s event on file %s", eventtype, iRODSPath)
-	svc.purgeCache(iRODSPath)
+	svc.purgeCache(eventtype, iRODSPath, uuid)
+}
+
+// isReady reports whether Connect() has completed and at least one event
+// has been observed, i.e. whether /readyz should return 200.
+func (svc *PurgemanService) isReady() bool {
+	return atomic.LoadInt32(&svc.connected) == 1 && atomic.LoadInt32(&svc.firstEventSeen) == 1
+}
+
+// lastEventTime returns the time of the last observed fs event, or the zero
+// time if none has been observed yet.
+func (svc *PurgemanService) lastEventTime() time.Time {
+	unixNano := atomic.LoadInt64(&svc.lastEventUnixNano)
+	if unixNano == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, unixNano)
 }
 
-// purgeCache purges cache
-func (svc *PurgemanService) purgeCache(path string) {
+// purgeCache schedules a debounced purge of path on every configured cache
+// backend. The actual PURGE requests are issued asynchronously by each
+// backend's CoalescingPurger once its debounce window elapses.
+func (svc *PurgemanService) purgeCache(eventType string, path string, uuid string) {
 	logger := log.WithFields(log.Fields{
 		"package":  "purgeman",
 		"function": "PurgemanService.purgeCache",
 	})
 
-	// purge cache on the path
-	logger.Infof("Purging a cache for %s", path)
-
-	wg := sync.WaitGroup{}
-	for idx, varnishURL := range svc.Config.VarnishURLPrefixes {
-		wg.Add(1)
-
-		f := func(urlPrefix string) {
-			defer wg.Done()
+	logger.Infof("Scheduling a purge of %s", path)
 
-			urlPrefix = strings.TrimRight(urlPrefix, "/")
-			requestURL := urlPrefix + path
-
-			hostOverride := ""
-			if idx < len(svc.Config.VarnishHostsOverride) {
-				hostOverride = svc.Config.VarnishHostsOverride[idx]
-			}
-
-			host := ""
-			if len(hostOverride) > 0 {
-				host = hostOverride
-			} else {
-				u, err := url.Parse(requestURL)
-				if err != nil {
-					logger.WithError(err).Errorf("Failed to aprse a request '%s'", requestURL)
-					return
-				}
-
-				host = u.Host
-			}
-
-			logger.Infof("Sending a PURGE request to '%s' for host '%s'", requestURL, host)
-
-			req, err := http.NewRequest("PURGE", requestURL, nil)
-			if err != nil {
-				logger.WithError(err).Errorf("Failed to create a PURGE request to url '%s' for host '%s'", requestURL, host)
-				return
-			}
-
-			if len(hostOverride) > 0 {
-				req.Host = hostOverride
-			}
-
-			req.SetBasicAuth(svc.Config.IRODSUsername, svc.Config.IRODSPassword)
-
-			response, err := http.DefaultClient.Do(req)
-			if err != nil {
-				logger.WithError(err).Errorf("Failed to make a PURGE request to url '%s' for host '%s'", requestURL, host)
-				return
-			}
-
-			if response.StatusCode < 200 || response.StatusCode >= 300 {
-				logger.Errorf("Unexpected response for a PURGE request to url '%s' for host '%s' - %s", requestURL, host, response.Status)
-				return
-			}
-
-			logger.Infof("Request is accepted!")
-		}
-
-		go f(varnishURL)
+	for _, purger := range svc.CachePurgers {
+		purger.Submit(eventType, path, uuid)
 	}
-
-	wg.Wait()
 }
@@ -0,0 +1,94 @@
+package purgeman
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// healthzResponse is the JSON body served by /healthz: the aggregated
+// health report plus the timestamp of the last fs event observed, so
+// operators can spot a source that's gone quiet without it tripping any
+// individual check.
+type healthzResponse struct {
+	HealthReport
+	LastEventTime *time.Time `json:"lastEventTime,omitempty"`
+}
+
+// newStatusServer builds (but does not start) the embedded HTTP server
+// exposing /healthz, /readyz and /metrics.
+func (svc *PurgemanService) newStatusServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", svc.handleHealthz)
+	mux.HandleFunc("/readyz", svc.handleReadyz)
+	mux.Handle("/metrics", promhttp.HandlerFor(svc.Metrics.Registry, promhttp.HandlerOpts{}))
+
+	return &http.Server{
+		Addr:    svc.Config.ListenAddr,
+		Handler: mux,
+	}
+}
+
+// startStatusServer starts the embedded HTTP server in the background, if
+// Config.ListenAddr is set.
+func (svc *PurgemanService) startStatusServer() {
+	if len(svc.Config.ListenAddr) == 0 {
+		return
+	}
+
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "PurgemanService.startStatusServer",
+	})
+
+	svc.statusServer = svc.newStatusServer()
+
+	go func() {
+		logger.Infof("Listening for health/metrics requests on %s", svc.Config.ListenAddr)
+		if err := svc.statusServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Error("Status server stopped unexpectedly")
+		}
+	}()
+}
+
+// stopStatusServer shuts down the embedded HTTP server, if running.
+func (svc *PurgemanService) stopStatusServer() {
+	if svc.statusServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc.statusServer.Shutdown(ctx) //nolint:errcheck
+	svc.statusServer = nil
+}
+
+func (svc *PurgemanService) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	resp := healthzResponse{HealthReport: svc.Health.Report()}
+	if t := svc.lastEventTime(); !t.IsZero() {
+		resp.LastEventTime = &t
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Health != "OK" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck
+}
+
+func (svc *PurgemanService) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !svc.isReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready\n")) //nolint:errcheck
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready\n")) //nolint:errcheck
+}
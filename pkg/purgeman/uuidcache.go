@@ -0,0 +1,153 @@
+package purgeman
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUUIDCacheSize   = 4096
+	defaultUUIDCacheTTL    = 5 * time.Minute
+	defaultUUIDNegativeTTL = 30 * time.Second
+)
+
+// UUIDPathCacheConfig configures a UUIDPathCache.
+type UUIDPathCacheConfig struct {
+	// Size bounds the number of entries kept (LRU-evicted). Defaults to
+	// 4096.
+	Size int `yaml:"size"`
+	// TTL is how long a resolved path stays cached. Defaults to 5m.
+	TTL time.Duration `yaml:"ttl"`
+	// NegativeTTL is how long a failed lookup (zero or >1 hits) stays
+	// cached, to stop a pathological UUID from repeatedly hitting iRODS.
+	// Defaults to 30s.
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+}
+
+func (c UUIDPathCacheConfig) withDefaults() UUIDPathCacheConfig {
+	if c.Size <= 0 {
+		c.Size = defaultUUIDCacheSize
+	}
+	if c.TTL <= 0 {
+		c.TTL = defaultUUIDCacheTTL
+	}
+	if c.NegativeTTL <= 0 {
+		c.NegativeTTL = defaultUUIDNegativeTTL
+	}
+
+	return c
+}
+
+// uuidCacheEntry is one LRU entry. A negative entry (negative == true)
+// records that uuid resolved to zero or more than one path.
+type uuidCacheEntry struct {
+	uuid      string
+	path      string
+	negative  bool
+	expiresAt time.Time
+}
+
+// UUIDPathCache is an LRU cache mapping an iRODS ipc_UUID metadata value to
+// its resolved path, with negative caching for UUIDs that don't resolve to
+// exactly one path.
+type UUIDPathCache struct {
+	config UUIDPathCacheConfig
+
+	mutex sync.Mutex
+	items map[string]*list.Element
+	order *list.List // front = most recently used
+}
+
+// NewUUIDPathCache creates an empty UUIDPathCache.
+func NewUUIDPathCache(config UUIDPathCacheConfig) *UUIDPathCache {
+	config = config.withDefaults()
+
+	return &UUIDPathCache{
+		config: config,
+		items:  make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+// Get returns the cached path for uuid and whether it was found. A negative
+// cache hit returns ("", true): found, but known to not resolve.
+func (c *UUIDPathCache) Get(uuid string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[uuid]
+	if !ok {
+		return "", false
+	}
+
+	entry := el.Value.(*uuidCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(el)
+		return "", false
+	}
+
+	c.order.MoveToFront(el)
+	if entry.negative {
+		return "", true
+	}
+
+	return entry.path, true
+}
+
+// Put caches a successful uuid -> path resolution.
+func (c *UUIDPathCache) Put(uuid string, path string) {
+	c.set(uuid, path, false, c.config.TTL)
+}
+
+// PutNegative caches that uuid resolved to zero or more than one path.
+func (c *UUIDPathCache) PutNegative(uuid string) {
+	c.set(uuid, "", true, c.config.NegativeTTL)
+}
+
+// Invalidate drops uuid's cache entry, e.g. because a rm/mv event means a
+// previously cached path is no longer current.
+func (c *UUIDPathCache) Invalidate(uuid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[uuid]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *UUIDPathCache) set(uuid string, path string, negative bool, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[uuid]; ok {
+		entry := el.Value.(*uuidCacheEntry)
+		entry.path = path
+		entry.negative = negative
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	entry := &uuidCacheEntry{
+		uuid:      uuid,
+		path:      path,
+		negative:  negative,
+		expiresAt: time.Now().Add(ttl),
+	}
+	c.items[uuid] = c.order.PushFront(entry)
+
+	if c.order.Len() > c.config.Size {
+		if oldest := c.order.Back(); oldest != nil {
+			c.removeElement(oldest)
+		}
+	}
+}
+
+// removeElement removes el from both the map and the LRU list. Callers must
+// hold c.mutex.
+func (c *UUIDPathCache) removeElement(el *list.Element) {
+	entry := el.Value.(*uuidCacheEntry)
+	delete(c.items, entry.uuid)
+	c.order.Remove(el)
+}
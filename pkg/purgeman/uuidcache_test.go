@@ -0,0 +1,109 @@
+package purgeman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUUIDPathCacheGetPut(t *testing.T) {
+	cases := []struct {
+		name        string
+		negative    bool
+		wantFound   bool
+		wantPath    string
+		wantLookAgn bool
+	}{
+		{name: "positive entry returns its path", negative: false, wantFound: true, wantPath: "/zone/home/file.txt"},
+		{name: "negative entry is found but returns an empty path", negative: true, wantFound: true, wantPath: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewUUIDPathCache(UUIDPathCacheConfig{TTL: time.Minute, NegativeTTL: time.Minute})
+
+			if tc.negative {
+				cache.PutNegative("uuid-1")
+			} else {
+				cache.Put("uuid-1", tc.wantPath)
+			}
+
+			path, found := cache.Get("uuid-1")
+			if found != tc.wantFound {
+				t.Fatalf("found = %v, want %v", found, tc.wantFound)
+			}
+			if path != tc.wantPath {
+				t.Fatalf("path = %q, want %q", path, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestUUIDPathCacheMiss(t *testing.T) {
+	cache := NewUUIDPathCache(UUIDPathCacheConfig{})
+
+	if _, found := cache.Get("never-cached"); found {
+		t.Fatal("Get() found = true for a uuid that was never cached")
+	}
+}
+
+func TestUUIDPathCacheExpiry(t *testing.T) {
+	ttl := 10 * time.Millisecond
+	negativeTTL := 20 * time.Millisecond
+	cache := NewUUIDPathCache(UUIDPathCacheConfig{TTL: ttl, NegativeTTL: negativeTTL})
+
+	cache.Put("positive", "/some/path")
+	cache.PutNegative("negative")
+
+	// well inside the positive TTL but past nothing yet
+	if _, found := cache.Get("positive"); !found {
+		t.Fatal("positive entry expired before its TTL elapsed")
+	}
+
+	time.Sleep(ttl + 5*time.Millisecond)
+	if _, found := cache.Get("positive"); found {
+		t.Fatal("positive entry should have expired after its TTL elapsed")
+	}
+
+	// the negative entry has a longer TTL and should still be live at this point
+	if _, found := cache.Get("negative"); !found {
+		t.Fatal("negative entry expired before its (longer) NegativeTTL elapsed")
+	}
+
+	time.Sleep(negativeTTL)
+	if _, found := cache.Get("negative"); found {
+		t.Fatal("negative entry should have expired after its NegativeTTL elapsed")
+	}
+}
+
+func TestUUIDPathCacheInvalidate(t *testing.T) {
+	cache := NewUUIDPathCache(UUIDPathCacheConfig{TTL: time.Minute})
+	cache.Put("uuid-1", "/some/path")
+
+	cache.Invalidate("uuid-1")
+
+	if _, found := cache.Get("uuid-1"); found {
+		t.Fatal("Get() found an invalidated entry")
+	}
+}
+
+func TestUUIDPathCacheLRUEviction(t *testing.T) {
+	cache := NewUUIDPathCache(UUIDPathCacheConfig{Size: 2, TTL: time.Minute})
+
+	cache.Put("uuid-1", "/path-1")
+	cache.Put("uuid-2", "/path-2")
+
+	// touch uuid-1 so uuid-2 becomes the least recently used entry
+	cache.Get("uuid-1")
+
+	cache.Put("uuid-3", "/path-3")
+
+	if _, found := cache.Get("uuid-2"); found {
+		t.Fatal("uuid-2 should have been evicted as the least recently used entry")
+	}
+	if _, found := cache.Get("uuid-1"); !found {
+		t.Fatal("uuid-1 should still be cached, it was the most recently used")
+	}
+	if _, found := cache.Get("uuid-3"); !found {
+		t.Fatal("uuid-3 should still be cached, it was just inserted")
+	}
+}
@@ -0,0 +1,171 @@
+package purgeman
+
+import (
+	"sync"
+	"time"
+
+	irodsfs_client "github.com/cyverse/go-irodsclient/fs"
+)
+
+const (
+	defaultUUIDBatchWindow        = 5 * time.Millisecond
+	defaultUUIDMaxParallelLookups = 8
+)
+
+// UUIDResolverConfig configures UUIDResolver's cache and lookup batching.
+type UUIDResolverConfig struct {
+	CacheSize        int           `yaml:"cache_size"`
+	CacheTTL         time.Duration `yaml:"cache_ttl"`
+	NegativeCacheTTL time.Duration `yaml:"negative_cache_ttl"`
+
+	// BatchWindow groups UUID lookups arriving within this window into one
+	// round of iRODS queries. Defaults to 5ms.
+	BatchWindow time.Duration `yaml:"batch_window"`
+	// MaxParallelLookups bounds how many SearchByMeta calls a batch round
+	// may run at once. Defaults to 8.
+	MaxParallelLookups int `yaml:"max_parallel_lookups"`
+}
+
+func (c UUIDResolverConfig) withDefaults() UUIDResolverConfig {
+	if c.BatchWindow <= 0 {
+		c.BatchWindow = defaultUUIDBatchWindow
+	}
+	if c.MaxParallelLookups <= 0 {
+		c.MaxParallelLookups = defaultUUIDMaxParallelLookups
+	}
+
+	return c
+}
+
+// UUIDResolver resolves iRODS ipc_UUID metadata values to paths. It caches
+// both positive and negative results (via UUIDPathCache) and coalesces
+// lookups that arrive within a few milliseconds of each other into one
+// batch, so a client rewriting many objects at once doesn't thrash the
+// iRODS connection with one SearchByMeta call per event.
+type UUIDResolver struct {
+	client *irodsfs_client.FileSystem
+	cache  *UUIDPathCache
+	config UUIDResolverConfig
+
+	mutex   sync.Mutex
+	waiters map[string][]chan string
+	timer   *time.Timer
+}
+
+// NewUUIDResolver creates a UUIDResolver backed by client.
+func NewUUIDResolver(client *irodsfs_client.FileSystem, config UUIDResolverConfig) *UUIDResolver {
+	config = config.withDefaults()
+
+	return &UUIDResolver{
+		client: client,
+		cache: NewUUIDPathCache(UUIDPathCacheConfig{
+			Size:        config.CacheSize,
+			TTL:         config.CacheTTL,
+			NegativeTTL: config.NegativeCacheTTL,
+		}),
+		config:  config,
+		waiters: make(map[string][]chan string),
+	}
+}
+
+// Resolve returns the path for uuid, or "" if it can't be resolved. It
+// blocks until uuid's batch round completes, which is at most BatchWindow
+// plus the time taken to look it up.
+func (r *UUIDResolver) Resolve(uuid string) string {
+	if path, found := r.cache.Get(uuid); found {
+		return path
+	}
+
+	waitCh := make(chan string, 1)
+
+	r.mutex.Lock()
+	r.waiters[uuid] = append(r.waiters[uuid], waitCh)
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.config.BatchWindow, r.flush)
+	}
+	r.mutex.Unlock()
+
+	return <-waitCh
+}
+
+// Invalidate drops uuid from the cache, e.g. on a rm/mv event whose stale
+// cached path would otherwise keep getting purged.
+func (r *UUIDResolver) Invalidate(uuid string) {
+	r.cache.Invalidate(uuid)
+}
+
+// flush takes the current batch of pending uuids and resolves all of them.
+func (r *UUIDResolver) flush() {
+	r.mutex.Lock()
+	batch := r.waiters
+	r.waiters = make(map[string][]chan string)
+	r.timer = nil
+	r.mutex.Unlock()
+
+	uuids := make([]string, 0, len(batch))
+	for uuid := range batch {
+		uuids = append(uuids, uuid)
+	}
+
+	results := r.lookupBatch(uuids)
+
+	for uuid, waiters := range batch {
+		for _, ch := range waiters {
+			ch <- results[uuid]
+		}
+	}
+}
+
+// lookupBatch resolves every uuid in uuids with bounded parallelism and
+// caches each result (positive or negative).
+//
+// go-irodsclient's SearchByMeta only accepts a single metadata value per
+// call, so this can't yet collapse the batch into one IN-style query; once
+// it supports that, this is where to switch to it.
+func (r *UUIDResolver) lookupBatch(uuids []string) map[string]string {
+	results := make(map[string]string, len(uuids))
+	resultsMutex := sync.Mutex{}
+
+	sem := make(chan struct{}, r.config.MaxParallelLookups)
+	wg := sync.WaitGroup{}
+
+	for _, uuid := range uuids {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(uuid string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			path := r.lookupOne(uuid)
+
+			resultsMutex.Lock()
+			results[uuid] = path
+			resultsMutex.Unlock()
+		}(uuid)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// lookupOne performs a single SearchByMeta call for uuid and caches the
+// result. A transient query error is deliberately left uncached: caching it
+// as a negative result would poison uuid for NegativeTTL and silently leave
+// stale content cached through a brief iRODS blip. Only a successful query
+// that resolves to zero or more than one path is a genuine negative result.
+func (r *UUIDResolver) lookupOne(uuid string) string {
+	entries, err := r.client.SearchByMeta("ipc_UUID", uuid)
+	if err != nil {
+		return ""
+	}
+
+	if len(entries) != 1 {
+		r.cache.PutNegative(uuid)
+		return ""
+	}
+
+	path := entries[0].Path
+	r.cache.Put(uuid, path)
+	return path
+}
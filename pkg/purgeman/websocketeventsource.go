@@ -0,0 +1,219 @@
+package purgeman
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	websocketDefaultPingInterval    = 30 * time.Second
+	websocketDefaultReconnectDelay  = 1 * time.Second
+	websocketDefaultMaxReconnectGap = 30 * time.Second
+)
+
+// WebsocketEventSourceConfig configures a WebsocketEventSource.
+type WebsocketEventSourceConfig struct {
+	// Name identifies the source for logging. Defaults to "websocket".
+	Name string `yaml:"name"`
+	// URL is the websocket endpoint to subscribe to, e.g.
+	// wss://arvados.example.org/websocket
+	URL string `yaml:"url"`
+	// BearerToken, if set, is sent as an Authorization: Bearer header on
+	// connect.
+	BearerToken string `yaml:"bearer_token"`
+	// PingInterval is how often to send a keepalive ping. Defaults to 30s.
+	PingInterval time.Duration `yaml:"ping_interval"`
+}
+
+// arvadosLogEvent is the JSON shape of an Arvados-style websocket log event.
+type arvadosLogEvent struct {
+	ID         int64  `json:"id"`
+	EventType  string `json:"event_type"`
+	ObjectUUID string `json:"object_uuid"`
+	ObjectPath string `json:"object_path"`
+}
+
+// WebsocketEventSource subscribes to an Arvados-style websocket log stream
+// and dispatches the fs events it carries. It reconnects with backoff on
+// disconnect, resuming from the last_log_id it last observed so events
+// aren't missed or replayed across reconnects.
+type WebsocketEventSource struct {
+	config WebsocketEventSourceConfig
+
+	mutex      sync.Mutex
+	conn       *websocket.Conn
+	closed     bool
+	lastLogID  int64
+	haveLastID bool
+}
+
+// NewWebsocketEventSource creates a WebsocketEventSource for config. It does
+// not connect until Monitor is called.
+func NewWebsocketEventSource(config WebsocketEventSourceConfig) *WebsocketEventSource {
+	if config.PingInterval <= 0 {
+		config.PingInterval = websocketDefaultPingInterval
+	}
+
+	return &WebsocketEventSource{
+		config: config,
+	}
+}
+
+// Name identifies the source for logging.
+func (ws *WebsocketEventSource) Name() string {
+	if len(ws.config.Name) > 0 {
+		return ws.config.Name
+	}
+
+	return "websocket"
+}
+
+// Monitor connects to the configured websocket endpoint and dispatches
+// events to handler, reconnecting with exponential backoff until Close is
+// called.
+func (ws *WebsocketEventSource) Monitor(handler EventHandler) error {
+	logger := log.WithFields(log.Fields{
+		"package":  "purgeman",
+		"function": "WebsocketEventSource.Monitor",
+		"source":   ws.Name(),
+	})
+
+	delay := websocketDefaultReconnectDelay
+	for {
+		if ws.isClosed() {
+			return nil
+		}
+
+		receivedEvent, err := ws.connectAndMonitor(handler, logger)
+
+		if ws.isClosed() {
+			return nil
+		}
+
+		if receivedEvent {
+			// the connection was good long enough to deliver at least one
+			// event, so whatever caused this disconnect isn't the steady
+			// failure the backoff is meant to ride out
+			delay = websocketDefaultReconnectDelay
+		}
+
+		if err != nil {
+			logger.WithError(err).Warnf("Websocket source disconnected, reconnecting in %s", delay)
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > websocketDefaultMaxReconnectGap {
+			delay = websocketDefaultMaxReconnectGap
+		}
+	}
+}
+
+// connectAndMonitor makes one connection attempt and reads events off it
+// until the connection drops or is closed. It reports whether at least one
+// event was successfully received, which Monitor uses to reset its backoff
+// delay.
+func (ws *WebsocketEventSource) connectAndMonitor(handler EventHandler, logger *log.Entry) (bool, error) {
+	headers := map[string][]string{}
+	if len(ws.config.BearerToken) > 0 {
+		headers["Authorization"] = []string{"Bearer " + ws.config.BearerToken}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(ws.config.URL, headers)
+	if err != nil {
+		return false, fmt.Errorf("failed to dial websocket '%s': %w", ws.config.URL, err)
+	}
+	defer conn.Close()
+
+	ws.mutex.Lock()
+	ws.conn = conn
+	ws.mutex.Unlock()
+
+	conn.SetPingHandler(func(data string) error {
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(5*time.Second))
+	})
+
+	// always (re)subscribe on connect - an Arvados server delivers nothing
+	// until it receives a subscribe frame, even on a brand new connection
+	// with no last_log_id yet.
+	subscribe := map[string]interface{}{
+		"method":  "subscribe",
+		"filters": [][]interface{}{},
+	}
+	if ws.haveLastID {
+		subscribe["last_log_id"] = ws.lastLogID
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		return false, fmt.Errorf("failed to send subscribe request: %w", err)
+	}
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go ws.keepAlive(conn, stopPing, logger)
+
+	receivedEvent := false
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return receivedEvent, err
+		}
+
+		event := arvadosLogEvent{}
+		if err := json.Unmarshal(message, &event); err != nil {
+			logger.WithError(err).Warn("Failed to unmarshal a websocket event")
+			continue
+		}
+
+		if event.ID > 0 {
+			ws.lastLogID = event.ID
+			ws.haveLastID = true
+		}
+
+		receivedEvent = true
+		handler(event.EventType, event.ObjectPath, event.ObjectUUID)
+	}
+}
+
+// keepAlive sends periodic pings until stop is closed.
+func (ws *WebsocketEventSource) keepAlive(conn *websocket.Conn, stop chan struct{}, logger *log.Entry) {
+	ticker := time.NewTicker(ws.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				logger.WithError(err).Debug("Failed to send a websocket ping")
+				return
+			}
+		}
+	}
+}
+
+// Close stops Monitor and closes the underlying connection, if any.
+func (ws *WebsocketEventSource) Close() error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	ws.closed = true
+	if ws.conn != nil {
+		return ws.conn.Close()
+	}
+
+	return nil
+}
+
+func (ws *WebsocketEventSource) isClosed() bool {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	return ws.closed
+}